@@ -0,0 +1,86 @@
+package raven
+
+import (
+	"go/build"
+	"sort"
+)
+
+// Client captures stacktraces and classifies their frames as InApp or not.
+// The zero value is not usable; construct one with NewClient.
+type Client struct {
+	classifier *moduleClassifier
+
+	// legacyPrefixes is the GOPATH-era package-prefix heuristic, consulted
+	// only when the classifier has no build info to work with.
+	legacyPrefixes []string
+
+	// CaptureLocals enables capturing local variable names and values for
+	// each stacktrace frame via LocalCapturer. Off by default: it relies
+	// on platform-specific frame-pointer introspection and is
+	// comparatively expensive.
+	CaptureLocals bool
+
+	// LocalCapturer supplies CaptureLocals' implementation. NewClient
+	// always sets it to the package's default capturer, which captures
+	// nothing (returns nil) on architectures it has no implementation
+	// for; set it to nil to disable capturing locals outright, or to
+	// your own implementation to replace the default. See the
+	// LocalCapturer doc comment for what the default can and can't do
+	// reliably.
+	LocalCapturer LocalCapturer
+
+	// RedactLocal, if set, is called for every local LocalCapturer
+	// produces before it's attached to a frame, so secrets can be
+	// scrubbed before transmission.
+	RedactLocal RedactLocalFunc
+}
+
+// NewClient returns a Client whose InApp classification is seeded from the
+// running binary's build info (its main module and resolved dependencies),
+// falling back to build.Default.SrcDirs() when build info is unavailable.
+func NewClient() *Client {
+	return &Client{
+		classifier:     newModuleClassifier(),
+		legacyPrefixes: build.Default.SrcDirs(),
+		LocalCapturer:  defaultLocalCapturer,
+	}
+}
+
+// SetInAppModules overrides which modules are classified InApp. It takes
+// precedence over both build info and the legacy prefix heuristic.
+func (c *Client) SetInAppModules(modules []string) {
+	c.classifier.setInAppModules(modules)
+}
+
+// SetSystemModules overrides which modules are never classified InApp. It
+// takes precedence over build info, but SetInAppModules wins over it.
+func (c *Client) SetSystemModules(modules []string) {
+	c.classifier.setSystemModules(modules)
+}
+
+// DependencyModules returns the modules, after resolving replace
+// directives, that the running binary's build info recorded as
+// dependencies of the main module. It returns nil if build info wasn't
+// available, in which case InApp classification falls back to
+// legacyPrefixes.
+func (c *Client) DependencyModules() []string {
+	deps := c.classifier.dependencies()
+	sort.Strings(deps)
+	return deps
+}
+
+// Stacktrace captures the current goroutine's stack, skipping `skip`
+// frames above its caller, with up to `context` lines of source around
+// each frame, and classifies each frame's InApp field using the client's
+// module configuration.
+func (c *Client) Stacktrace(skip, context int) *Stacktrace {
+	st := NewStacktrace(skip+1, context, c.legacyPrefixes)
+	if st == nil {
+		return nil
+	}
+	for _, f := range st.Frames {
+		f.InApp = c.classifier.classify(f.Module, c.legacyPrefixes)
+		c.captureLocals(f)
+	}
+	return st
+}
@@ -0,0 +1,55 @@
+package raven
+
+// Local is a single captured local variable or argument from a stacktrace
+// frame, in the spirit of Sentry's Python/Java SDKs' frame.vars.
+type Local struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// LocalCapturer captures the locals in scope in a stacktrace frame.
+// Implementations are necessarily platform- and toolchain-specific:
+// reading a live frame's locals means walking the frame-pointer chain and
+// resolving variable names and locations from the binary's own DWARF debug
+// info, none of which the runtime package exposes directly.
+//
+// NewClient wires up a default implementation, frameArgCapturer, on
+// architectures it supports; see that type's doc comment for what it can
+// and can't do reliably. Set Client.LocalCapturer to nil to disable
+// capturing, or to your own implementation (e.g. DWARF-backed) to replace
+// it.
+type LocalCapturer interface {
+	// CaptureLocals returns the locals in scope in the frame identified by
+	// pc and fp (that frame's program counter and frame pointer, as
+	// captured by NewStacktrace), or nil if it can't determine any (e.g.
+	// the frame has no debug info, or fp is on an unsupported
+	// architecture).
+	CaptureLocals(pc, fp uintptr) []Local
+}
+
+// RedactLocalFunc is called for every local a Client's LocalCapturer
+// produces, before it's attached to a frame, so secrets (tokens,
+// passwords) can be scrubbed before transmission. frameFunc is the
+// frame's function name, as reported in StacktraceFrame.Function.
+type RedactLocalFunc func(frameFunc, name string, val interface{}) interface{}
+
+// captureLocals runs the client's LocalCapturer over frame's pc, applying
+// RedactLocal to each result. It is a no-op if CaptureLocals is off or no
+// LocalCapturer is configured.
+func (c *Client) captureLocals(frame *StacktraceFrame) {
+	if !c.CaptureLocals || c.LocalCapturer == nil {
+		return
+	}
+
+	vars := c.LocalCapturer.CaptureLocals(frame.pc, frame.fp)
+	if len(vars) == 0 {
+		return
+	}
+
+	if c.RedactLocal != nil {
+		for i, v := range vars {
+			vars[i].Value = c.RedactLocal(frame.Function, v.Name, v.Value)
+		}
+	}
+	frame.Vars = vars
+}
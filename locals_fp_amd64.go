@@ -0,0 +1,48 @@
+//go:build amd64
+// +build amd64
+
+package raven
+
+import "unsafe"
+
+// getFP returns the x86-64 BP register as set by getFP's caller's prologue,
+// i.e. the caller's own frame pointer. Implemented in getfp_amd64.s.
+func getFP() uintptr
+
+// walkFramePointers returns the frame pointers of n stack frames, skipping
+// skip frames above walkFramePointers' own (skip=0 returns
+// walkFramePointers' own frame pointer as fps[0], skip=1 its caller's, and
+// so on), by following the saved-BP chain each Go frame on this
+// architecture maintains. It stops early, returning fewer than n entries,
+// if the chain bottoms out (a nil frame pointer) first.
+//
+// Callers match this up against a runtime.Callers(skipCallers, pcs) by
+// passing skip = skipCallers, since both walkFramePointers and getFP add
+// exactly one frame each above walkFramePointers' caller, the same two
+// frames runtime.Callers' own skip param accounts for internally --
+// NewStacktrace relies on this exact alignment to pair each pcs[i] with
+// fps[i]. //go:noinline on both this function and getFP (the latter
+// because it's asm, which the compiler never inlines) keeps that frame
+// count from silently changing out from under that arithmetic.
+//
+// `go vet`'s unsafeptr check flags the uintptr<->Pointer round-trips
+// below: it can't tell these came from a CPU register read, not a real Go
+// pointer, so it assumes the worst. That's an unavoidable property of
+// walking raw stack addresses this way, not a bug; see frameArgCapturer's
+// doc comment in locals_frame.go for the accuracy tradeoffs that come
+// with it.
+//
+//go:noinline
+func walkFramePointers(skip, n int) []uintptr {
+	fp := getFP()
+	for i := 0; i < skip && fp != 0; i++ {
+		fp = *(*uintptr)(unsafe.Pointer(fp))
+	}
+
+	fps := make([]uintptr, 0, n)
+	for i := 0; i < n && fp != 0; i++ {
+		fps = append(fps, fp)
+		fp = *(*uintptr)(unsafe.Pointer(fp))
+	}
+	return fps
+}
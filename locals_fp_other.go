@@ -0,0 +1,9 @@
+//go:build !amd64
+// +build !amd64
+
+package raven
+
+// walkFramePointers has no implementation outside amd64: see
+// locals_fp_amd64.go and getfp_amd64.s. frameArgCapturer degrades to
+// capturing nothing wherever it returns nil.
+func walkFramePointers(skip, n int) []uintptr { return nil }
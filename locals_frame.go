@@ -0,0 +1,92 @@
+package raven
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+//go:linkname runtimeFindfunc runtime.findfunc
+func runtimeFindfunc(pc uintptr) runtimeFuncInfo
+
+// runtimeFuncInfo and runtimeFunc mirror just enough of runtime.funcInfo
+// and runtime._func (see runtime/symtab.go) for argsSizeAt to reach
+// _func.args. Neither is part of the Go compatibility promise and both
+// have changed shape across releases; a mismatch here doesn't corrupt
+// memory (argsSizeAt only ever reads fi.fn, a real function pointer
+// runtime.findfunc handed back), it just makes frameArgCapturer's output
+// wrong, which is the risk its doc comment already calls out.
+type runtimeFuncInfo struct {
+	fn    uintptr
+	datap uintptr
+}
+
+type runtimeFunc struct {
+	entryOff    uint32
+	nameOff     int32
+	args        int32
+	deferreturn uint32
+}
+
+// argsSizeAt returns the byte size of pc's function's combined
+// argument/result area, or -1 if pc can't be resolved to a function.
+func argsSizeAt(pc uintptr) int32 {
+	fi := runtimeFindfunc(pc)
+	if fi.fn == 0 {
+		return -1
+	}
+	return (*runtimeFunc)(unsafe.Pointer(fi.fn)).args
+}
+
+// frameArgCapturer is the default LocalCapturer, wired up by NewClient on
+// architectures walkFramePointers supports (currently amd64). It reads a
+// frame's incoming arguments directly off the stack: runtime.findfunc
+// (reached via //go:linkname, since it isn't exported) gives the size of
+// the function's argument area, and walkFramePointers' saved-BP chain
+// gives the address of that frame's copy of it.
+//
+// This only reads the stack slots Go's calling convention reserves for
+// arguments (starting 16 bytes above the frame pointer: past the saved
+// caller PC and the saved caller frame pointer), not live register
+// contents. Under the register-based ABIInternal calling convention Go
+// has used since 1.17, the compiler is free to keep a non-escaping
+// argument entirely in a register and never spill it to that reserved
+// area -- in a normal optimized build, frameArgCapturer frequently finds
+// stale or zero bytes there instead of the real value. It's accurate for
+// binaries built with optimizations and inlining disabled (`go build
+// -gcflags=all=-N -l`), which is how its output should be verified;
+// treat what it reports from a normally-built binary as a best-effort
+// hint, not ground truth. It also has no DWARF to name variables, so
+// Local.Name is just the argument word's position, not its real name.
+//
+// Supply your own LocalCapturer, e.g. one backed by DWARF or a debugger
+// protocol, if you need locals that are reliably correct in optimized
+// production builds.
+type frameArgCapturer struct{}
+
+// defaultLocalCapturer is what NewClient assigns to Client.LocalCapturer,
+// so CaptureLocals does something out of the box instead of silently
+// capturing nothing. Client.LocalCapturer can still be set to nil, or to
+// a different implementation, to opt out or replace it.
+var defaultLocalCapturer LocalCapturer = frameArgCapturer{}
+
+// CaptureLocals implements LocalCapturer.
+func (frameArgCapturer) CaptureLocals(pc, fp uintptr) []Local {
+	if fp == 0 {
+		return nil
+	}
+	size := argsSizeAt(pc)
+	if size <= 0 {
+		return nil
+	}
+
+	base := fp + 16
+	vars := make([]Local, 0, size/8)
+	for off := int32(0); off < size; off += 8 {
+		word := *(*uint64)(unsafe.Pointer(base + uintptr(off)))
+		vars = append(vars, Local{
+			Name:  fmt.Sprintf("arg+%#x", off),
+			Value: word,
+		})
+	}
+	return vars
+}
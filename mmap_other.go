@@ -0,0 +1,32 @@
+//go:build windows
+// +build windows
+
+package raven
+
+import (
+	"io/ioutil"
+)
+
+// mmapFile is a read-only memory mapping of a file on disk. On platforms
+// without a syscall.Mmap implementation in this file, it falls back to
+// reading the file into memory once; callers should not rely on mmapFile
+// to bound process RSS on these platforms.
+type mmapFile struct {
+	data []byte
+}
+
+// openMmap "maps" the named file into memory for read-only access. See the
+// platform caveat on mmapFile.
+func openMmap(path string) (*mmapFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapFile{data: data}, nil
+}
+
+// Data returns the mapped file contents.
+func (m *mmapFile) Data() []byte { return m.data }
+
+// Close releases the mapping.
+func (m *mmapFile) Close() error { return nil }
@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package raven
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile is a read-only memory mapping of a file on disk.
+type mmapFile struct {
+	f    *os.File
+	data []byte
+}
+
+// openMmap maps the named file into memory for read-only access. The
+// returned mmapFile must be closed when no longer needed.
+func openMmap(path string) (*mmapFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		// syscall.Mmap rejects zero-length mappings; there's nothing to map.
+		return &mmapFile{f: f, data: nil}, nil
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &mmapFile{f: f, data: data}, nil
+}
+
+// Data returns the mapped file contents.
+func (m *mmapFile) Data() []byte { return m.data }
+
+// Close unmaps the file and closes the underlying descriptor.
+func (m *mmapFile) Close() error {
+	var err error
+	if m.data != nil {
+		err = syscall.Munmap(m.data)
+	}
+	if cerr := m.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
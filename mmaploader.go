@@ -0,0 +1,156 @@
+package raven
+
+import (
+	"bytes"
+	"container/list"
+	"os"
+	"sync"
+)
+
+// mmapLoader is a Loader that mmaps each source file it reads context from
+// exactly once, keeping a line-offset table rather than a slice of line
+// byte slices, and evicts the least recently used mapping once the
+// combined size of mapped files would exceed a configurable byte budget.
+//
+// Use it in place of the default fsLoader, via SetSourceLoader, in
+// memory-sensitive, long-running processes where fsLoader's unbounded
+// per-file cache is undesirable.
+type mmapLoader struct {
+	mu      sync.Mutex
+	budget  int64 // 0 means unbounded
+	used    int64
+	entries map[string]*list.Element
+	lru     *list.List // front = most recently used
+}
+
+// mmapEntry is one mapped source file, plus enough metadata to detect that
+// it has changed on disk since it was mapped.
+type mmapEntry struct {
+	filename string
+	file     *mmapFile
+	size     int64
+	modTime  int64
+	offsets  []int64
+}
+
+// NewMmapLoader returns a Loader backed by memory-mapped source files. Once
+// the combined size of mapped files would exceed budget bytes, the least
+// recently used mapping is unmapped to make room; a budget of 0 means
+// unbounded, matching fsLoader's behavior but without the whole-file reads.
+func NewMmapLoader(budget int64) Loader {
+	return &mmapLoader{
+		budget:  budget,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Load implements Loader.
+func (m *mmapLoader) Load(filename string, context, line int) ([][]byte, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.entryLocked(filename)
+	if err != nil {
+		return nil, 0
+	}
+
+	lower, upper, contextLine := boundedRange(entry.offsets, line, context)
+	if lower >= upper {
+		return nil, 0
+	}
+
+	// splitRange must run, and its result must be fully copied out of the
+	// mapping, before m.mu is released: otherwise a concurrent Load that
+	// evicts this entry can unmap entry.file's memory out from under the
+	// lines we're about to return.
+	return splitRange(entry.file.Data(), entry.offsets, lower, upper), contextLine - lower
+}
+
+// entryLocked returns the mmapEntry for filename, mapping it (or re-mapping
+// it, if it changed size or mtime since it was last mapped) as needed. Must
+// be called with m.mu held.
+func (m *mmapLoader) entryLocked(filename string) (*mmapEntry, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if elem, ok := m.entries[filename]; ok {
+		entry := elem.Value.(*mmapEntry)
+		if entry.size == info.Size() && entry.modTime == info.ModTime().UnixNano() {
+			m.lru.MoveToFront(elem)
+			return entry, nil
+		}
+		m.removeLocked(elem)
+	}
+
+	mf, err := openMmap(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &mmapEntry{
+		filename: filename,
+		file:     mf,
+		size:     info.Size(),
+		modTime:  info.ModTime().UnixNano(),
+		offsets:  lineOffsets(mf.Data()),
+	}
+	elem := m.lru.PushFront(entry)
+	m.entries[filename] = elem
+	m.used += entry.size
+
+	m.evictLocked()
+	return entry, nil
+}
+
+// evictLocked unmaps least-recently-used entries until m.used is within
+// budget, always keeping at least the most recently used entry: a single
+// file larger than the budget is still usable, just not cached alongside
+// anything else. Must be called with m.mu held.
+func (m *mmapLoader) evictLocked() {
+	if m.budget <= 0 {
+		return
+	}
+	for m.used > m.budget && m.lru.Len() > 1 {
+		m.removeLocked(m.lru.Back())
+	}
+}
+
+func (m *mmapLoader) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*mmapEntry)
+	m.lru.Remove(elem)
+	delete(m.entries, entry.filename)
+	m.used -= entry.size
+	entry.file.Close()
+}
+
+// splitRange slices the mmapped file data down to the line range
+// [lower, upper), using offsets to avoid re-scanning data for newlines.
+// Each returned line is copied into its own freshly allocated []byte:
+// data is mmapped memory that a concurrent eviction can unmap once the
+// caller (mmapLoader.Load) releases its lock, so the result must not
+// alias it, unlike the in-memory fsLoader/packageIndex cases where
+// slicing the backing array is safe.
+func splitRange(data []byte, offsets []int64, lower, upper int) [][]byte {
+	start := offsets[lower]
+	end := int64(len(data))
+	if upper < len(offsets) {
+		end = offsets[upper]
+	}
+
+	raw := bytes.Split(data[start:end], []byte{'\n'})
+	if upper < len(offsets) && len(raw) > 0 && len(raw[len(raw)-1]) == 0 {
+		raw = raw[:len(raw)-1] // drop the artifact of splitting at a trailing newline
+	}
+
+	lines := make([][]byte, upper-lower)
+	for i := range lines {
+		if i >= len(raw) {
+			break
+		}
+		lines[i] = append([]byte(nil), raw[i]...)
+	}
+	return lines
+}
@@ -0,0 +1,145 @@
+package raven
+
+import (
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// moduleClassifier decides whether a stacktrace frame is InApp based on the
+// module path of the frame's function. It prefers the running binary's
+// build info (so it works correctly under Go modules, where ImportDir-based
+// guessing breaks down) and falls back to a caller-supplied package-prefix
+// heuristic only when build info isn't available.
+type moduleClassifier struct {
+	mu sync.RWMutex
+
+	mainModule string
+	depModules map[string]bool // modules (post-replace) the main module depends on
+
+	inAppModules  []string // explicit overrides, checked before build info
+	systemModules []string // explicit overrides, checked before inAppModules
+}
+
+// defaultClassifier is used by callers, like ParseStacktrace, that need
+// module-aware InApp classification without a Client of their own.
+var defaultClassifier = newModuleClassifier()
+
+// SetInAppModules overrides which modules ParseStacktrace classifies as
+// InApp, taking precedence over both build info and the legacy prefix
+// heuristic. It configures the package-level classifier ParseStacktrace
+// uses and has no effect on any Client; see Client.SetInAppModules for
+// that.
+func SetInAppModules(modules []string) {
+	defaultClassifier.setInAppModules(modules)
+}
+
+// SetSystemModules overrides which modules ParseStacktrace never
+// classifies as InApp. See SetInAppModules for how it relates to Client.
+func SetSystemModules(modules []string) {
+	defaultClassifier.setSystemModules(modules)
+}
+
+// newModuleClassifier builds a classifier seeded from runtime/debug's view
+// of the running binary's module graph. If build info isn't available
+// (e.g. a binary built with `go build` outside a module, or with
+// -trimpath in a configuration that strips it), mainModule is left empty
+// and classify falls back entirely to its legacyPrefixes argument.
+func newModuleClassifier() *moduleClassifier {
+	c := &moduleClassifier{depModules: make(map[string]bool)}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return c
+	}
+
+	c.mainModule = info.Main.Path
+	for _, dep := range info.Deps {
+		mod := dep
+		for mod.Replace != nil {
+			mod = mod.Replace
+		}
+		c.depModules[mod.Path] = true
+	}
+	return c
+}
+
+// classify reports whether a frame whose function belongs to module should
+// be marked InApp. legacyPrefixes is only consulted when the classifier has
+// no build info to work with.
+func (c *moduleClassifier) classify(module string, legacyPrefixes []string) bool {
+	c.mu.RLock()
+	inAppModules := c.inAppModules
+	systemModules := c.systemModules
+	mainModule := c.mainModule
+	c.mu.RUnlock()
+
+	if matchesModule(module, systemModules) {
+		return false
+	}
+	if matchesModule(module, inAppModules) {
+		return true
+	}
+
+	if mainModule != "" {
+		// module is "main" for every frame in the entry package, no matter
+		// the main module's real import path: functionName derives it from
+		// the function name the runtime reports (e.g. "main.main"), and Go
+		// always renders the entry package's name as literal "main" there,
+		// never the module path. matchesModule can never match that, so
+		// it's special-cased here instead of being silently misclassified.
+		if module == "main" {
+			return true
+		}
+		// Build info is available: trust it over the legacy heuristic,
+		// even if that means marking stdlib frames as not InApp. Whether
+		// module is a known dependency or something build info doesn't
+		// recognize (stdlib, linkname'd runtime internals), it's not
+		// InApp unless it's the main module.
+		return matchesModule(module, []string{mainModule})
+	}
+
+	for _, prefix := range legacyPrefixes {
+		if strings.HasPrefix(module, prefix) && !strings.Contains(module, "vendor") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesModule reports whether module is, or is a subpackage of, one of
+// the given module paths.
+func matchesModule(module string, modules []string) bool {
+	for _, m := range modules {
+		if module == m || strings.HasPrefix(module, m+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *moduleClassifier) setInAppModules(modules []string) {
+	c.mu.Lock()
+	c.inAppModules = modules
+	c.mu.Unlock()
+}
+
+func (c *moduleClassifier) setSystemModules(modules []string) {
+	c.mu.Lock()
+	c.systemModules = modules
+	c.mu.Unlock()
+}
+
+// dependencies returns the modules (after resolving replace directives)
+// the main module depends on, as recorded in the running binary's build
+// info. It returns nil if build info wasn't available.
+func (c *moduleClassifier) dependencies() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	deps := make([]string, 0, len(c.depModules))
+	for mod := range c.depModules {
+		deps = append(deps, mod)
+	}
+	return deps
+}
@@ -0,0 +1,24 @@
+package raven
+
+import "testing"
+
+func TestModuleClassifierClassify(t *testing.T) {
+	c := &moduleClassifier{mainModule: "example.com/myapp"}
+
+	tests := []struct {
+		name   string
+		module string
+		want   bool
+	}{
+		{"main package", "main", true},
+		{"main module subpackage", "example.com/myapp/foo", true},
+		{"unrelated dependency", "example.com/somedep", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := c.classify(test.module, nil); got != test.want {
+				t.Errorf("classify(%q) = %v, want %v", test.module, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package raven
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// defaultParseContextLines is how many lines of source context
+// ParseStacktrace keeps around each frame. It matches what callers
+// typically pass to NewStacktrace for panic reporting.
+const defaultParseContextLines = 3
+
+var (
+	goroutineHeaderRe = regexp.MustCompile(`^goroutine \d+ \[[^\]]+\]:$`)
+	frameLocationRe   = regexp.MustCompile(`^\t(.+):(\d+)(?:\s+\+0x[0-9a-fA-F]+)?\s*(?:.*)$`)
+	createdByRe       = regexp.MustCompile(`^created by (.+?)(?:\s+in goroutine \d+)?$`)
+)
+
+// ParseStacktrace reconstructs Stacktrace values -- with file, line,
+// function, module, and (via the configured source Loader) surrounding
+// source context -- from the text a `runtime.Stack(buf, true)` call, or a
+// SIGQUIT crash dump that embeds the same per-goroutine traceback format,
+// writes for every running goroutine.
+//
+// This lets operators re-ingest crashes captured out-of-band, e.g. by a
+// sidecar that tailed stderr after the process died, without needing a
+// live PC the way NewStacktrace does.
+//
+// Each returned *Stacktrace corresponds to one "goroutine N [status]:"
+// block in dump, in the order they appear, with frames ordered oldest to
+// newest to match NewStacktrace. A trailing "created by ..." frame, if
+// present, becomes the oldest frame. Lines this parser doesn't recognize
+// (thread/register dumps from a debug=2 SIGQUIT, panic banners, "exit
+// status" trailers from a wrapping shell, and the like) are skipped rather
+// than treated as errors, so a ragged dump still yields whatever
+// goroutines it can parse. InApp classification uses the running
+// process's own module (see the package-level SetInAppModules /
+// SetSystemModules, which configure this independently of any Client's),
+// since a dump's frames generally belong to this same binary; it's
+// best-effort for a dump ingested by a different binary than the one that
+// produced it.
+func ParseStacktrace(dump []byte) ([]*Stacktrace, error) {
+	lines := strings.Split(string(dump), "\n")
+
+	var traces []*Stacktrace
+	var frames []*StacktraceFrame
+
+	flush := func() {
+		if len(frames) == 0 {
+			return
+		}
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+		traces = append(traces, &Stacktrace{Frames: frames})
+		frames = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case goroutineHeaderRe.MatchString(line):
+			flush()
+			continue
+		case strings.TrimSpace(line) == "":
+			flush()
+			continue
+		}
+
+		funcName, ok := parseFrameFunc(line)
+		if !ok || i+1 >= len(lines) {
+			continue // preamble, trailer, or a line we don't recognize
+		}
+
+		lm := frameLocationRe.FindStringSubmatch(lines[i+1])
+		if lm == nil {
+			continue
+		}
+		i++ // consumed the location line
+
+		lineNo, err := strconv.Atoi(lm[2])
+		if err != nil {
+			continue
+		}
+
+		frame := &StacktraceFrame{
+			AbsolutePath: lm[1],
+			Filename:     trimFilename(lm[1]),
+			Lineno:       lineNo,
+		}
+		frame.Module, frame.Function = functionName(funcName)
+		populateSourceContext(frame, defaultParseContextLines)
+		frame.InApp = defaultClassifier.classify(frame.Module, nil)
+
+		frames = append(frames, frame)
+	}
+	flush()
+
+	return traces, nil
+}
+
+// parseFrameFunc extracts the fully qualified function name from a
+// traceback's call line, e.g. "main.(*Worker).run(0xc0000a0000)" or
+// "created by main.(*Pool).spawn in goroutine 7", tolerating the
+// "(inline)"/"[inline]" markers some tools append to inlined frames. It
+// reports false for lines that aren't call lines at all, such as the
+// location line underneath one, or diagnostic noise.
+func parseFrameFunc(line string) (string, bool) {
+	if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, " ") {
+		return "", false
+	}
+
+	name := line
+	if m := createdByRe.FindStringSubmatch(name); m != nil {
+		name = m[1]
+	}
+	name = strings.TrimSuffix(name, " (inline)")
+	name = strings.TrimSuffix(name, " [inline]")
+
+	// Strip the argument list, if there is one, by pairing parens from the
+	// end: a method frame such as "main.(*Worker).run(0xc0000a0000)" has
+	// one around its pointer receiver too, so the first '(' from either
+	// end isn't reliably the start of the argument list. "created by"
+	// lines have no argument list at all, just the (possibly
+	// parenthesized) receiver, so leave those alone.
+	if strings.HasSuffix(name, ")") {
+		depth := 0
+	parenScan:
+		for i := len(name) - 1; i >= 0; i-- {
+			switch name[i] {
+			case ')':
+				depth++
+			case '(':
+				depth--
+				if depth == 0 {
+					name = name[:i]
+					break parenScan
+				}
+			}
+		}
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,47 @@
+package raven
+
+import "testing"
+
+const sampleGoroutineDump = `goroutine 1 [running]:
+main.(*Worker).run(0xc0000a0000)
+	/tmp/src/worker.go:42 +0x1b
+main.main()
+	/tmp/src/main.go:10 +0x25
+created by main.(*Pool).spawn in goroutine 7
+	/tmp/src/pool.go:77 +0x99
+`
+
+func TestParseStacktrace(t *testing.T) {
+	traces, err := ParseStacktrace([]byte(sampleGoroutineDump))
+	if err != nil {
+		t.Fatalf("ParseStacktrace returned error: %v", err)
+	}
+	if len(traces) != 1 {
+		t.Fatalf("got %d traces, want 1", len(traces))
+	}
+
+	frames := traces[0].Frames
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames, want 3", len(frames))
+	}
+
+	// Oldest to newest: the created-by trailer, then main, then the
+	// innermost frame.
+	want := []struct {
+		module, function string
+		lineno           int
+	}{
+		{"main", "(*Pool).spawn", 77},
+		{"main", "main", 10},
+		{"main", "(*Worker).run", 42},
+	}
+	for i, w := range want {
+		f := frames[i]
+		if f.Module != w.module || f.Function != w.function {
+			t.Errorf("frame %d: got %s.%s, want %s.%s", i, f.Module, f.Function, w.module, w.function)
+		}
+		if f.Lineno != w.lineno {
+			t.Errorf("frame %d: got line %d, want %d", i, f.Lineno, w.lineno)
+		}
+	}
+}
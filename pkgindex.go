@@ -0,0 +1,277 @@
+package raven
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// packageIndex maintains a persistent, on-disk index of source line offsets
+// for the package directories raven-go has loaded stacktrace context from.
+// The index for a directory is built once and written to disk, so that a
+// long-running process reporting many frames from the same large file pays
+// a bounded, one-time cost rather than re-reading and re-splitting the file
+// on every lookup, and a later process (or a directory evicted from
+// pi.dirs) can pick the index back up with a quick mmap-and-decode instead
+// of rebuilding it. Staleness is detected from each file's size and mtime
+// rather than its content, so a lookup never has to re-read a whole file
+// just to find out its cached entry is still good.
+//
+// A packageIndex is safe for concurrent use. A nil *packageIndex is valid
+// and simply disables indexing, falling back to a plain read of the file.
+type packageIndex struct {
+	mu   sync.Mutex
+	dirs map[string]*dirIndex
+
+	// cacheDir is where index blobs are persisted. Exposed as a field
+	// (rather than a package var) so tests can point it at a temp dir.
+	cacheDir string
+}
+
+// dirIndex is the decoded, in-memory view of one package directory's index
+// blob. Nothing keeps the on-disk blob mapped once it's decoded; see
+// loadIndex.
+type dirIndex struct {
+	Files map[string]fileEntry // base name -> entry
+}
+
+// fileEntry records enough about a source file to detect that it has
+// changed since it was indexed, plus the byte offset of the start of each
+// line so a lookup can seek directly to the requested range. Staleness is
+// Size and ModTime only: hashing a file's content to detect changes would
+// mean reading the whole thing, which is exactly what this index exists to
+// avoid on the lookup path.
+type fileEntry struct {
+	Size    int64
+	ModTime int64
+	Offsets []int64
+}
+
+func newPackageIndex() *packageIndex {
+	return &packageIndex{
+		dirs:     make(map[string]*dirIndex),
+		cacheDir: filepath.Join(os.TempDir(), "raven-go-srcidx"),
+	}
+}
+
+// rangeLines returns the `context` lines of source surrounding `line` in
+// filename, using the on-disk package index to seek directly to that byte
+// range instead of reading and splitting the whole file. ok is false if
+// there is no usable index for filename (no index, stale entry, or it
+// isn't present in its directory's index), in which case the caller should
+// fall back to a plain read.
+func (pi *packageIndex) rangeLines(filename string, line, context int) (lines [][]byte, index int, ok bool) {
+	if pi == nil {
+		return nil, 0, false
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, 0, false
+	}
+
+	dir := filepath.Dir(filename)
+	base := filepath.Base(filename)
+
+	di := pi.dirIndexFor(dir)
+	entry, found := di.Files[base]
+	if !found || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		// Missing or stale: let the caller read the file directly, and the
+		// next rebuild of this directory's index will pick up the change.
+		return nil, 0, false
+	}
+
+	lower, upper, contextLine := boundedRange(entry.Offsets, line, context)
+	if lower > upper {
+		return nil, 0, false
+	}
+
+	lines, err = readByteRange(filename, entry.Offsets, lower, upper)
+	if err != nil {
+		return nil, 0, false
+	}
+	return lines, contextLine - lower, true
+}
+
+// dirIndexFor returns the loaded index for dir, loading it from disk or
+// building it from scratch if necessary.
+func (pi *packageIndex) dirIndexFor(dir string) *dirIndex {
+	pi.mu.Lock()
+	defer pi.mu.Unlock()
+
+	if di, ok := pi.dirs[dir]; ok {
+		return di
+	}
+
+	di := pi.loadIndex(dir)
+	if di == nil {
+		di = pi.buildIndex(dir)
+	}
+	pi.dirs[dir] = di
+	return di
+}
+
+// indexPath returns where dir's index blob is (or would be) persisted.
+func (pi *packageIndex) indexPath(dir string) string {
+	h := sha256.Sum256([]byte(dir))
+	return filepath.Join(pi.cacheDir, encodeHex(h[:])+".idx")
+}
+
+// loadIndex mmaps and decodes a previously persisted index blob for dir, or
+// returns nil if none exists or it fails to decode. The mapping is only
+// needed long enough for gob to decode out of it; di.Files holds its own
+// copy of everything afterward, so the blob is closed before returning
+// rather than kept mapped for the life of the process.
+func (pi *packageIndex) loadIndex(dir string) *dirIndex {
+	blob, err := openMmap(pi.indexPath(dir))
+	if err != nil {
+		return nil
+	}
+	defer blob.Close()
+
+	var di dirIndex
+	if err := gob.NewDecoder(bytes.NewReader(blob.Data())).Decode(&di.Files); err != nil {
+		return nil
+	}
+	return &di
+}
+
+// buildIndex walks dir once, computing a line-offset table for each
+// regular file, and persists the result so future lookups (in this
+// process or a later one) can skip straight to loadIndex.
+func (pi *packageIndex) buildIndex(dir string) *dirIndex {
+	di := &dirIndex{Files: make(map[string]fileEntry)}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return di
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		di.Files[e.Name()] = fileEntry{
+			Size:    e.Size(),
+			ModTime: e.ModTime().UnixNano(),
+			Offsets: lineOffsets(data),
+		}
+	}
+
+	pi.persistIndex(dir, di)
+	return di
+}
+
+// persistIndex writes di to disk so a later process (or dirIndexFor, for a
+// directory evicted from pi.dirs) can load it via loadIndex instead of
+// rebuilding it. Failures here are non-fatal: di is still usable purely
+// in-memory for this run. It doesn't map the blob back: di already holds
+// everything loadIndex would decode from it, so there's nothing to gain
+// from keeping a second copy mapped.
+func (pi *packageIndex) persistIndex(dir string, di *dirIndex) {
+	if err := os.MkdirAll(pi.cacheDir, 0o755); err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(di.Files); err != nil {
+		return
+	}
+
+	_ = ioutil.WriteFile(pi.indexPath(dir), buf.Bytes(), 0o644)
+}
+
+// lineOffsets returns the byte offset, within data, of the first byte of
+// each line (line 0 always starts at offset 0).
+func lineOffsets(data []byte) []int64 {
+	offsets := []int64{0}
+	for i, b := range data {
+		if b == '\n' && i+1 < len(data) {
+			offsets = append(offsets, int64(i+1))
+		}
+	}
+	return offsets
+}
+
+// boundedRange clamps the [line-context-1, line+context) line range (plus
+// the 1-indexed target line itself) to the bounds of a file with
+// len(offsets) lines, returning the lower and upper line indices and the
+// index of the target line, all relative to the start of the file.
+func boundedRange(offsets []int64, line, context int) (lower, upper, contextLine int) {
+	n := len(offsets)
+	clamp := func(i int) int {
+		if i < 0 {
+			return 0
+		}
+		if i > n {
+			return n
+		}
+		return i
+	}
+	return clamp(line - context - 1), clamp(line + context), clamp(line - 1)
+}
+
+// readByteRange opens filename and reads just the bytes spanning line
+// indices [lower, upper) according to offsets, splitting them back into
+// individual lines. This is the seek the package index exists to enable:
+// for a large file and a small context window, it reads a few hundred
+// bytes instead of the whole file.
+func readByteRange(filename string, offsets []int64, lower, upper int) ([][]byte, error) {
+	if lower >= upper {
+		return nil, nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	start := offsets[lower]
+	var end int64
+	if upper < len(offsets) {
+		end = offsets[upper]
+	} else {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		end = info.Size()
+	}
+
+	buf := make([]byte, end-start)
+	if _, err := f.ReadAt(buf, start); err != nil {
+		return nil, err
+	}
+
+	raw := bytes.Split(buf, []byte{'\n'})
+	// A trailing split element after the range's final newline is an
+	// artifact of the split, not a real line, unless we're at EOF.
+	if upper < len(offsets) && len(raw) > 0 && len(raw[len(raw)-1]) == 0 {
+		raw = raw[:len(raw)-1]
+	}
+
+	lines := make([][]byte, upper-lower)
+	copy(lines, raw)
+	return lines, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+func encodeHex(b []byte) string {
+	out := make([]byte, len(b)*2)
+	for i, c := range b {
+		out[i*2] = hexDigits[c>>4]
+		out[i*2+1] = hexDigits[c&0xf]
+	}
+	return string(out)
+}
@@ -0,0 +1,319 @@
+package raven
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Stacktrace mirrors the JSON renderings of Sentry's stacktrace interface.
+type Stacktrace struct {
+	Frames []*StacktraceFrame `json:"frames,omitempty"`
+}
+
+// Class implements the Interface interface.
+func (s *Stacktrace) Class() string { return "stacktrace" }
+
+// Culprit returns the "module.function" of the innermost frame, which Sentry
+// uses to group events when no other culprit is supplied.
+func (s *Stacktrace) Culprit() string {
+	if s == nil || len(s.Frames) == 0 {
+		return ""
+	}
+	topFrame := s.Frames[len(s.Frames)-1]
+	if topFrame.Module != "" && topFrame.Function != "" {
+		return fmt.Sprintf("%s.%s", topFrame.Module, topFrame.Function)
+	}
+	return topFrame.Function
+}
+
+// StacktraceFrame represents a single entry in a stacktrace.
+type StacktraceFrame struct {
+	Filename     string   `json:"filename"`
+	Function     string   `json:"function"`
+	Module       string   `json:"module,omitempty"`
+	Lineno       int      `json:"lineno"`
+	AbsolutePath string   `json:"abs_path,omitempty"`
+	ContextLine  string   `json:"context_line,omitempty"`
+	PreContext   []string `json:"pre_context,omitempty"`
+	PostContext  []string `json:"post_context,omitempty"`
+	InApp        bool     `json:"in_app"`
+	Vars         []Local  `json:"vars,omitempty"`
+
+	// pc and fp are the frame's program counter and, where
+	// walkFramePointers supports the current architecture, frame pointer
+	// (un-exported, so neither reaches the JSON sent to Sentry) so that a
+	// Client with CaptureLocals enabled can resolve local variables for
+	// this frame after NewStacktrace has already built it. An inlined
+	// frame shares its enclosing physical frame's fp, since it has no
+	// stack frame of its own.
+	pc uintptr
+	fp uintptr
+}
+
+// NewStacktrace walks the goroutine stack starting `skip` frames above the
+// caller of NewStacktrace, keeping up to `context` lines of surrounding
+// source around each frame. appPackagePrefixes marks a frame InApp when its
+// module has one of the given prefixes and isn't vendored.
+//
+// It walks the stack with runtime.Callers and resolves frames with
+// runtime.CallersFrames rather than repeated runtime.Caller calls, so that
+// inlined calls are reported as their own frames instead of being folded
+// into their caller's.
+//
+// Where walkFramePointers supports the running architecture, it also
+// walks the frame-pointer chain alongside pcs, one physical frame per
+// entry, so each resulting StacktraceFrame carries the fp a LocalCapturer
+// needs alongside its pc. This is why frames are resolved one pcs entry
+// at a time below instead of through a single runtime.CallersFrames(pcs)
+// pass: an inlined call can expand one physical pcs entry into several
+// logical frames, but only the outermost of those actually owns that
+// entry's stack frame (and so its argument area) -- the inlined ones
+// never had a frame of their own. Inlined frames get fp 0 rather than
+// the enclosing frame's, so a LocalCapturer can tell the difference
+// instead of silently attributing the wrong function's arguments to
+// them; see runtime.Frame.Func's doc comment for how that's detected.
+func NewStacktrace(skip int, context int, appPackagePrefixes []string) *Stacktrace {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(2+skip, pcs)
+		if n == 0 {
+			return nil
+		}
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+
+	fps := walkFramePointers(skip+2, len(pcs))
+
+	var frames []*StacktraceFrame
+	for i := range pcs {
+		var fp uintptr
+		if i < len(fps) {
+			fp = fps[i]
+		}
+
+		physicalFrames := runtime.CallersFrames(pcs[i : i+1])
+		for {
+			rf, more := physicalFrames.Next()
+
+			frame := newStacktraceFrame(rf, frameFP(rf, fp), context, appPackagePrefixes)
+			if frame != nil {
+				frames = append(frames, frame)
+			}
+			if !more {
+				break
+			}
+		}
+	}
+
+	// Sentry wants frames ordered from oldest to newest.
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+
+	if len(frames) == 0 {
+		return nil
+	}
+	return &Stacktrace{frames}
+}
+
+// frameFP returns the frame pointer a LocalCapturer should use for rf,
+// given physicalFP (the frame pointer of the physical stack frame rf was
+// symbolized from). rf.Func is nil when rf is a call inlined into that
+// physical frame: it never had a stack frame of its own, so physicalFP is
+// its enclosing caller's, not its own, and must not be attributed to it.
+func frameFP(rf runtime.Frame, physicalFP uintptr) uintptr {
+	if rf.Func == nil {
+		return 0
+	}
+	return physicalFP
+}
+
+func newStacktraceFrame(rf runtime.Frame, fp uintptr, context int, appPackagePrefixes []string) *StacktraceFrame {
+	file := rf.File
+
+	frame := &StacktraceFrame{
+		AbsolutePath: file,
+		Filename:     trimFilename(file),
+		Lineno:       rf.Line,
+		pc:           rf.PC,
+		fp:           fp,
+	}
+	frame.Module, frame.Function = functionName(rf.Function)
+
+	populateSourceContext(frame, context)
+	classifyLegacy(frame, appPackagePrefixes)
+
+	return frame
+}
+
+// populateSourceContext fills in frame's ContextLine, PreContext and
+// PostContext from the configured sourceLoader, given its AbsolutePath and
+// Lineno are already set.
+func populateSourceContext(frame *StacktraceFrame, context int) {
+	contextLines, index := sourceLoader.Load(frame.AbsolutePath, context, frame.Lineno)
+	if len(contextLines) == 0 {
+		return
+	}
+	for i, l := range contextLines {
+		switch {
+		case i < index:
+			frame.PreContext = append(frame.PreContext, string(l))
+		case i == index:
+			frame.ContextLine = string(l)
+		default:
+			frame.PostContext = append(frame.PostContext, string(l))
+		}
+	}
+}
+
+// classifyLegacy sets frame.InApp using the GOPATH-era package-prefix
+// heuristic: true iff frame.Module has one of appPackagePrefixes and isn't
+// vendored.
+func classifyLegacy(frame *StacktraceFrame, appPackagePrefixes []string) {
+	for _, prefix := range appPackagePrefixes {
+		if strings.HasPrefix(frame.Module, prefix) && !strings.Contains(frame.Module, "vendor") {
+			frame.InApp = true
+			return
+		}
+	}
+}
+
+// trimFilename strips GOPATH/module-cache source roots from an absolute path,
+// leaving the import-path-relative name Sentry expects in "filename".
+func trimFilename(file string) string {
+	for _, dir := range build.Default.SrcDirs() {
+		dir := dir + string(filepath.Separator)
+		if trimmed := strings.TrimPrefix(file, dir); len(trimmed) < len(file) {
+			return trimmed
+		}
+	}
+	return file
+}
+
+// functionName splits a fully qualified runtime function name such as
+// "github.com/cockroachdb/raven-go.NewStacktrace" into its package and
+// function parts.
+func functionName(name string) (pack, fun string) {
+	pack = name
+	if idx := strings.LastIndex(pack, "/"); idx != -1 {
+		fun = pack[idx+1:]
+	} else {
+		fun = pack
+	}
+
+	idx := strings.Index(fun, ".")
+	if idx != -1 {
+		pack = pack[:len(pack)-len(fun)+idx]
+		fun = fun[idx+1:]
+	}
+	fun = strings.Replace(fun, "·", ".", -1)
+	return
+}
+
+// Loader loads `context` lines of source surrounding `line` from a file,
+// returning the lines and the index of `line` within them. Implementations
+// must be safe for concurrent use.
+type Loader interface {
+	Load(filename string, context, line int) (lines [][]byte, index int)
+}
+
+// sourceLoader is the Loader NewStacktrace uses to populate context lines.
+// It defaults to fsLoader and can be overridden with SetSourceLoader.
+var sourceLoader Loader = newFsLoader()
+
+// SetSourceLoader overrides the Loader used to fetch stacktrace source
+// context. It is not safe to call concurrently with stacktrace capture.
+func SetSourceLoader(loader Loader) {
+	sourceLoader = loader
+}
+
+// fsLoader reads whole source files from disk and caches their lines
+// in memory, keyed by absolute path. The cache has no eviction policy and
+// no bound on its size: it is intended for short-lived processes and tests,
+// not long-running servers with a large, rarely-repeating set of source
+// files. See packageIndex for a bounded alternative.
+type fsLoader struct {
+	mu    sync.Mutex
+	cache map[string][][]byte
+	index *packageIndex
+}
+
+func newFsLoader() *fsLoader {
+	return &fsLoader{
+		cache: make(map[string][][]byte),
+		index: newPackageIndex(),
+	}
+}
+
+// Load returns up to 2*context+1 lines of source centered on line (1-indexed),
+// along with the index of `line` within the returned slice.
+func (fs *fsLoader) Load(filename string, context, line int) ([][]byte, int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if lines, ok := fs.cache[filename]; ok {
+		if lines == nil {
+			return nil, 0
+		}
+		return calculateContextLines(lines, line, context)
+	}
+
+	// Prefer the on-disk package index: it can seek straight to the
+	// requested byte range without reading the rest of the file, and
+	// without re-caching it here.
+	if lines, index, ok := fs.index.rangeLines(filename, line, context); ok {
+		return lines, index
+	}
+
+	lines, err := readFile(filename)
+	if err != nil {
+		fs.cache[filename] = nil
+		return nil, 0
+	}
+	fs.cache[filename] = lines
+
+	return calculateContextLines(lines, line, context)
+}
+
+// calculateContextLines slices `lines` (1-indexed by `line`) down to the
+// `context` lines before and after it, clamping to the bounds of the file,
+// and returns the index of `line` within the slice.
+func calculateContextLines(lines [][]byte, line, context int) ([][]byte, int) {
+	lower := boundedIndex(line-context-1, lines)
+	upper := boundedIndex(line+context, lines)
+	contextLine := boundedIndex(line-1, lines)
+	if lower > upper {
+		return nil, 0
+	}
+	return lines[lower:upper], contextLine - lower
+}
+
+func boundedIndex(i int, lines [][]byte) int {
+	if i < 0 {
+		return 0
+	}
+	if i > len(lines) {
+		return len(lines)
+	}
+	return i
+}
+
+// readFile reads an entire file and splits it into lines, the fallback used
+// by the package index when it has no cached entry for a file.
+func readFile(filename string) ([][]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Split(data, []byte{'\n'}), nil
+}
@@ -212,3 +212,28 @@ func TestFileContext(t *testing.T) {
 		}
 	}
 }
+
+func TestFrameFP(t *testing.T) {
+	pcs := make([]uintptr, 1)
+	if runtime.Callers(1, pcs) == 0 {
+		t.Fatal("runtime.Callers returned no frames")
+	}
+	withFunc, _ := runtime.CallersFrames(pcs).Next()
+	if withFunc.Func == nil {
+		t.Fatal("expected a real *runtime.Func for this test's own PC")
+	}
+
+	const physicalFP = uintptr(0xdeadbeef)
+	if got := frameFP(withFunc, physicalFP); got != physicalFP {
+		t.Errorf("frameFP for a physical frame = %#x; want %#x", got, physicalFP)
+	}
+
+	// A call inlined into a physical frame is reported with Func == nil;
+	// it has no stack frame of its own, so the physical frame's fp must
+	// not be attributed to it.
+	inlined := withFunc
+	inlined.Func = nil
+	if got := frameFP(inlined, physicalFP); got != 0 {
+		t.Errorf("frameFP for an inlined frame = %#x; want 0", got)
+	}
+}